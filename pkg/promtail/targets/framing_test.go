@@ -0,0 +1,107 @@
+package targets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestReadDelimitedFrame(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString("hello\x00world\x00"))
+
+	frame, n, err := readDelimitedFrame(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(frame), "hello"; got != want {
+		t.Fatalf("frame = %q, want %q", got, want)
+	}
+	if n != 6 {
+		t.Fatalf("n = %d, want 6", n)
+	}
+
+	frame, _, err = readDelimitedFrame(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(frame), "world"; got != want {
+		t.Fatalf("frame = %q, want %q", got, want)
+	}
+}
+
+func TestReadDelimitedFramePartial(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString("partial, no delimiter yet"))
+	_, _, err := readDelimitedFrame(r, 0)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadLengthPrefixedFrame(t *testing.T) {
+	var buf bytes.Buffer
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, 5)
+	buf.Write(lenBuf[:n])
+	buf.WriteString("hello")
+
+	r := bufio.NewReader(&buf)
+	frame, read, err := readLengthPrefixedFrame(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(frame), "hello"; got != want {
+		t.Fatalf("frame = %q, want %q", got, want)
+	}
+	if read != n+5 {
+		t.Fatalf("n = %d, want %d", read, n+5)
+	}
+}
+
+// TestReadLengthPrefixedFramePartialPayload exercises the "writer hasn't
+// finished the frame yet" case: the length prefix is complete but the
+// payload is still being written. That hits io.ErrUnexpectedEOF from
+// io.ReadFull, which must be normalized to io.EOF so it's treated as an
+// incomplete frame to wait on rather than a hard read error.
+func TestReadLengthPrefixedFramePartialPayload(t *testing.T) {
+	var buf bytes.Buffer
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, 5)
+	buf.Write(lenBuf[:n])
+	buf.WriteString("hel") // only 3 of 5 payload bytes written so far
+
+	r := bufio.NewReader(&buf)
+	_, _, err := readLengthPrefixedFrame(r, 0)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadLengthPrefixedFramePartialPrefix(t *testing.T) {
+	// A uvarint continuation byte with nothing following it.
+	r := bufio.NewReader(bytes.NewBuffer([]byte{0x80}))
+	_, _, err := readLengthPrefixedFrame(r, 0)
+	if err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+}
+
+// TestReadLengthPrefixedFrameOverCap checks that a declared length beyond
+// maxFrameBytes is rejected before the payload allocation, e.g. from a
+// bit-flipped or out-of-sync prefix, instead of driving a huge make([]byte).
+func TestReadLengthPrefixedFrameOverCap(t *testing.T) {
+	var buf bytes.Buffer
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, 1<<40)
+	buf.Write(lenBuf[:n])
+
+	r := bufio.NewReader(&buf)
+	_, read, err := readLengthPrefixedFrame(r, 1024)
+	if err != errFrameTooLarge {
+		t.Fatalf("err = %v, want errFrameTooLarge", err)
+	}
+	if read != n {
+		t.Fatalf("n = %d, want %d (prefix only, no payload read attempted)", read, n)
+	}
+}
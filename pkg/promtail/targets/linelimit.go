@@ -0,0 +1,97 @@
+package targets
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// LineLimitConfig bounds how large a single raw line from the tailed file
+// can grow before promtail steps in. MaxLineSize is threaded straight into
+// the underlying tail library, which splits any line it reads past that
+// size into several Lines entries with no trailing newline; MaxLineBytes is
+// promtail's own hard cap on how much of a split line it will re-join
+// before truncating it.
+type LineLimitConfig struct {
+	MaxLineSize  int
+	MaxLineBytes int
+}
+
+var (
+	lineSplits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "line_splits_total",
+		Help:      "Number of line fragments the tail library split out of an over-long line.",
+	}, []string{"path"})
+
+	truncatedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "truncated_lines_total",
+		Help:      "Number of split lines that hit MaxLineBytes and were truncated.",
+	}, []string{"path"})
+)
+
+// assembleSplitLine folds the raw chunks the tail library hands back for an
+// over-long line into a single logical line, truncating once the re-joined
+// text hits cfg.MaxLineBytes. ready is true once chunk completes a logical
+// line (whether whole or truncated); joined is only meaningful when ready.
+func (t *tailer) assembleSplitLine(chunk string) (joined string, ready bool) {
+	chunkLen := len(chunk)
+	continuing := t.lineLimits.MaxLineSize > 0 && chunkLen >= t.lineLimits.MaxLineSize
+
+	if !t.inSplitLine && !continuing {
+		// The common case: a normal, complete line.
+		readBytes.WithLabelValues(t.path).Add(float64(chunkLen + 1))
+		return chunk, true
+	}
+
+	lineSplits.WithLabelValues(t.path).Inc()
+	if continuing {
+		// This fragment isn't newline-terminated: it hit MaxLineSize and the
+		// tail library is about to hand us more of the same logical line, so
+		// don't add the synthetic +1 byte readBytes otherwise uses to
+		// account for a line's trailing newline.
+		readBytes.WithLabelValues(t.path).Add(float64(chunkLen))
+	} else {
+		// This is the terminal fragment of a split line (inSplitLine was
+		// already true), the one that actually hits the real newline, so
+		// the +1 belongs here too.
+		readBytes.WithLabelValues(t.path).Add(float64(chunkLen + 1))
+	}
+
+	if t.truncatingLine {
+		if !continuing {
+			t.inSplitLine = false
+			t.truncatingLine = false
+		}
+		return "", false
+	}
+
+	t.inSplitLine = true
+	t.splitLine.WriteString(chunk)
+	t.splitLineBytes += chunkLen
+
+	if t.lineLimits.MaxLineBytes > 0 && t.splitLineBytes >= t.lineLimits.MaxLineBytes {
+		truncatedLines.WithLabelValues(t.path).Inc()
+		joined = fmt.Sprintf("%s...[truncated %d bytes]", t.splitLine.String(), t.splitLineBytes)
+		t.splitLine.Reset()
+		t.splitLineBytes = 0
+		if continuing {
+			t.truncatingLine = true
+		} else {
+			t.inSplitLine = false
+		}
+		return joined, true
+	}
+
+	if continuing {
+		return "", false
+	}
+
+	t.inSplitLine = false
+	joined = t.splitLine.String()
+	t.splitLine.Reset()
+	t.splitLineBytes = 0
+	return joined, true
+}
@@ -0,0 +1,84 @@
+package targets
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAssembleSplitLineWholeLine(t *testing.T) {
+	tr := &tailer{path: "whole", lineLimits: LineLimitConfig{MaxLineSize: 10, MaxLineBytes: 100}}
+
+	joined, ready := tr.assembleSplitLine("short")
+	if !ready || joined != "short" {
+		t.Fatalf("got (%q, %v), want (\"short\", true)", joined, ready)
+	}
+	if got := testutil.ToFloat64(readBytes.WithLabelValues(tr.path)); got != 6 {
+		t.Fatalf("readBytes = %v, want 6 (len+newline)", got)
+	}
+}
+
+// TestAssembleSplitLineAccounting exercises a line split across three
+// fragments by the tail library (two MaxLineSize-sized continuations
+// followed by a short final fragment) and checks that readBytes tracks the
+// real file size: each continuation contributes its raw length, and the
+// terminal fragment additionally contributes the real trailing newline.
+func TestAssembleSplitLineAccounting(t *testing.T) {
+	tr := &tailer{path: "split", lineLimits: LineLimitConfig{MaxLineSize: 5}}
+
+	// First fragment is exactly MaxLineSize long, so the library continues it.
+	joined, ready := tr.assembleSplitLine("aaaaa")
+	if ready {
+		t.Fatalf("expected first fragment to not be ready, got joined=%q", joined)
+	}
+	// Second fragment, also at the size cap: still continuing.
+	joined, ready = tr.assembleSplitLine("bbbbb")
+	if ready {
+		t.Fatalf("expected second fragment to not be ready, got joined=%q", joined)
+	}
+	// Final, short fragment completes the logical line.
+	joined, ready = tr.assembleSplitLine("c")
+	if !ready || joined != "aaaaabbbbbc" {
+		t.Fatalf("got (%q, %v), want (\"aaaaabbbbbc\", true)", joined, ready)
+	}
+
+	want := float64(5 + 5 + 1 + 1) // two continuations + terminal fragment + its newline
+	if got := testutil.ToFloat64(readBytes.WithLabelValues(tr.path)); got != want {
+		t.Fatalf("readBytes = %v, want %v", got, want)
+	}
+	if got := testutil.ToFloat64(lineSplits.WithLabelValues(tr.path)); got != 3 {
+		t.Fatalf("lineSplits = %v, want 3", got)
+	}
+}
+
+func TestAssembleSplitLineTruncation(t *testing.T) {
+	tr := &tailer{path: "truncated", lineLimits: LineLimitConfig{MaxLineSize: 5, MaxLineBytes: 8}}
+
+	// Two continuations push splitLineBytes to 10, over the 8-byte cap.
+	if _, ready := tr.assembleSplitLine("aaaaa"); ready {
+		t.Fatal("expected first fragment to not be ready")
+	}
+	joined, ready := tr.assembleSplitLine("bbbbb")
+	if !ready {
+		t.Fatal("expected truncation to make the second fragment ready")
+	}
+	if want := "aaaaabbbbb...[truncated 10 bytes]"; joined != want {
+		t.Fatalf("joined = %q, want %q", joined, want)
+	}
+
+	// A further continuation fragment is discarded (still truncating)...
+	if joined, ready := tr.assembleSplitLine("ccccc"); ready {
+		t.Fatalf("expected discarded continuation to not be ready, got joined=%q", joined)
+	}
+	// ...until the real terminal fragment arrives: run()'s `if !ready {
+	// continue }` contract means it still isn't emitted (the truncated
+	// placeholder already went out above), but it still contributes its
+	// newline to readBytes.
+	if joined, ready := tr.assembleSplitLine("d"); ready || joined != "" {
+		t.Fatalf("got (%q, %v), want (\"\", false)", joined, ready)
+	}
+
+	if got := testutil.ToFloat64(truncatedLines.WithLabelValues(tr.path)); got != 1 {
+		t.Fatalf("truncatedLines = %v, want 1", got)
+	}
+}
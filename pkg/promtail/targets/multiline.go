@@ -0,0 +1,66 @@
+package targets
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	entriesEmitted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "entries_emitted_total",
+		Help:      "Number of logical entries emitted to the handler.",
+	}, []string{"path"})
+
+	entriesTruncated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "promtail",
+		Name:      "multiline_entries_truncated_total",
+		Help:      "Number of multiline entries truncated because they hit MaxLines/MaxBytes.",
+	}, []string{"path"})
+)
+
+// MultilineConfig configures assembly of several physical lines into a
+// single logical entry, e.g. for Java stack traces or Python tracebacks.
+// Disabled unless FirstLine is set.
+type MultilineConfig struct {
+	// FirstLine matches the first line of a new logical entry; any line
+	// that doesn't match is appended to the entry currently being assembled.
+	FirstLine *regexp.Regexp
+	// MaxWait is the longest we'll hold a partial entry waiting for more
+	// lines before flushing it anyway.
+	MaxWait time.Duration
+	// MaxLines and MaxBytes cap how large a single assembled entry can grow;
+	// once either is hit the entry is flushed early.
+	MaxLines int
+	MaxBytes int
+}
+
+// multilineBuffer accumulates physical lines into a single logical entry.
+type multilineBuffer struct {
+	text      strings.Builder
+	firstTime time.Time
+	lines     int
+}
+
+func (b *multilineBuffer) empty() bool {
+	return b.lines == 0
+}
+
+func (b *multilineBuffer) append(t time.Time, line string) {
+	if b.lines == 0 {
+		b.firstTime = t
+	} else {
+		b.text.WriteByte('\n')
+	}
+	b.text.WriteString(line)
+	b.lines++
+}
+
+func (b *multilineBuffer) reset() {
+	b.text.Reset()
+	b.lines = 0
+}
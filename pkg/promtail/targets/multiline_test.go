@@ -0,0 +1,50 @@
+package targets
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultilineBufferAppend(t *testing.T) {
+	var b multilineBuffer
+	if !b.empty() {
+		t.Fatal("expected new buffer to be empty")
+	}
+
+	t0 := time.Now()
+	b.append(t0, "first line")
+	if b.empty() {
+		t.Fatal("expected buffer to be non-empty after append")
+	}
+	if b.firstTime != t0 {
+		t.Fatalf("firstTime = %v, want %v", b.firstTime, t0)
+	}
+	if got, want := b.text.String(), "first line"; got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+
+	b.append(t0.Add(time.Second), "second line")
+	if got, want := b.text.String(), "first line\nsecond line"; got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+	if b.lines != 2 {
+		t.Fatalf("lines = %d, want 2", b.lines)
+	}
+	// firstTime is only set by the line that started the entry.
+	if b.firstTime != t0 {
+		t.Fatalf("firstTime = %v, want unchanged %v", b.firstTime, t0)
+	}
+}
+
+func TestMultilineBufferReset(t *testing.T) {
+	var b multilineBuffer
+	b.append(time.Now(), "line")
+	b.reset()
+
+	if !b.empty() {
+		t.Fatal("expected buffer to be empty after reset")
+	}
+	if b.text.String() != "" {
+		t.Fatalf("text = %q, want empty", b.text.String())
+	}
+}
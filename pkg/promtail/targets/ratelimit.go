@@ -0,0 +1,80 @@
+package targets
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var droppedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "promtail",
+	Name:      "dropped_lines_total",
+	Help:      "Number of lines skipped before being handled, by reason.",
+}, []string{"path", "reason"})
+
+// RateLimitConfig configures the per-file leaky-bucket rate limiter used by
+// the tailer to protect ingesters from log loops. It is disabled by default;
+// setting Burst and Rate/Per enables it.
+type RateLimitConfig struct {
+	// Burst is the maximum number of lines the bucket can admit in a single burst.
+	Burst int64
+	// Rate is the number of tokens added to the bucket every Per.
+	Rate int64
+	// Per is the duration over which Rate tokens are refilled.
+	Per time.Duration
+}
+
+// leakyBucket is a simple token bucket rate limiter, sized by Burst and
+// refilled at Rate tokens per Per. It is safe for concurrent use.
+type leakyBucket struct {
+	mtx          sync.Mutex
+	size         float64
+	fillInterval time.Duration
+	ratePerFill  int64
+	lastEvent    time.Time
+	current      float64
+
+	// now stands in for time.Now in tests; nil means use the real clock.
+	now func() time.Time
+}
+
+func newLeakyBucket(cfg RateLimitConfig) *leakyBucket {
+	return &leakyBucket{
+		size:         float64(cfg.Burst),
+		fillInterval: cfg.Per,
+		ratePerFill:  cfg.Rate,
+		lastEvent:    time.Now(),
+		current:      float64(cfg.Burst),
+	}
+}
+
+// admit reports whether a token could be taken from the bucket, refilling it
+// based on the elapsed time since the last call. current is kept as a float
+// so that calls arriving faster than one fill interval per token still
+// accrue a fractional token instead of losing the elapsed time outright.
+func (b *leakyBucket) admit() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	if b.now != nil {
+		now = b.now()
+	}
+	elapsed := now.Sub(b.lastEvent)
+	b.lastEvent = now
+
+	if b.fillInterval > 0 {
+		b.current += float64(elapsed) * float64(b.ratePerFill) / float64(b.fillInterval)
+		if b.current > b.size {
+			b.current = b.size
+		}
+	}
+
+	if b.current < 1 {
+		return false
+	}
+	b.current--
+	return true
+}
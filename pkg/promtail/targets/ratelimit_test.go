@@ -0,0 +1,63 @@
+package targets
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLeakyBucketSustainedRate checks that a bucket fed faster than its
+// refill interval still converges on its configured rate instead of
+// degrading into a one-time burst gate once the initial burst drains.
+func TestLeakyBucketSustainedRate(t *testing.T) {
+	now := time.Now()
+	b := newLeakyBucket(RateLimitConfig{Burst: 50, Rate: 100, Per: time.Second})
+	b.lastEvent = now
+	b.now = func() time.Time { return now }
+
+	admitted := 0
+	step := time.Second / 200 // 200 calls/sec, faster than the 100/s fill rate
+	for i := 0; i < 600; i++ {
+		now = now.Add(step)
+		if b.admit() {
+			admitted++
+		}
+	}
+
+	// Over 3s at 100/s the bucket can admit roughly burst + rate*elapsed.
+	want := 50 + 300
+	if admitted < want-5 || admitted > want+5 {
+		t.Fatalf("admitted %d tokens over 3s at rate=100/s burst=50, want ~%d", admitted, want)
+	}
+}
+
+func TestLeakyBucketAdmit(t *testing.T) {
+	now := time.Now()
+	b := newLeakyBucket(RateLimitConfig{Burst: 2, Rate: 1, Per: time.Second})
+	b.lastEvent = now
+	b.now = func() time.Time { return now }
+
+	if !b.admit() {
+		t.Fatal("expected first call to admit from initial burst")
+	}
+	if !b.admit() {
+		t.Fatal("expected second call to admit from initial burst")
+	}
+	if b.admit() {
+		t.Fatal("expected third call to be denied once burst is drained")
+	}
+}
+
+func TestLeakyBucketDisabledRefill(t *testing.T) {
+	now := time.Now()
+	b := newLeakyBucket(RateLimitConfig{Burst: 1})
+	b.lastEvent = now
+	b.now = func() time.Time { return now }
+
+	if !b.admit() {
+		t.Fatal("expected burst token to admit")
+	}
+	now = now.Add(time.Hour)
+	if b.admit() {
+		t.Fatal("expected bucket with no fill interval to never refill")
+	}
+}
@@ -0,0 +1,32 @@
+package targets
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rotationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "promtail",
+	Name:      "rotations_total",
+	Help:      "Number of times a tailed file was detected as rotated or truncated.",
+}, []string{"path", "reason"})
+
+// fileSnapshot captures the identity and size of a file at a point in time,
+// used to detect in-place truncation (copytruncate) and rotation of files
+// that aren't symlinks, both of which the underlying tail library can miss.
+type fileSnapshot struct {
+	dev, inode uint64
+	size       int64
+}
+
+// rotationReason compares snapshot to prev and reports whether a rotation or
+// truncation occurred, and why.
+func rotationReason(prev, snapshot fileSnapshot, lastOffset int64) (reason string, rotated bool) {
+	if prev.dev != snapshot.dev || prev.inode != snapshot.inode {
+		return "rename", true
+	}
+	if snapshot.size < lastOffset {
+		return "truncate", true
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package targets
+
+import "testing"
+
+func TestRotationReason(t *testing.T) {
+	cases := []struct {
+		name       string
+		prev       fileSnapshot
+		snapshot   fileSnapshot
+		lastOffset int64
+		reason     string
+		rotated    bool
+	}{
+		{
+			name:       "unchanged",
+			prev:       fileSnapshot{dev: 1, inode: 1, size: 100},
+			snapshot:   fileSnapshot{dev: 1, inode: 1, size: 150},
+			lastOffset: 100,
+			rotated:    false,
+		},
+		{
+			name:       "inode changed",
+			prev:       fileSnapshot{dev: 1, inode: 1, size: 100},
+			snapshot:   fileSnapshot{dev: 1, inode: 2, size: 10},
+			lastOffset: 100,
+			reason:     "rename",
+			rotated:    true,
+		},
+		{
+			name:       "dev changed",
+			prev:       fileSnapshot{dev: 1, inode: 1, size: 100},
+			snapshot:   fileSnapshot{dev: 2, inode: 1, size: 10},
+			lastOffset: 100,
+			reason:     "rename",
+			rotated:    true,
+		},
+		{
+			name:       "truncated in place",
+			prev:       fileSnapshot{dev: 1, inode: 1, size: 100},
+			snapshot:   fileSnapshot{dev: 1, inode: 1, size: 10},
+			lastOffset: 100,
+			reason:     "truncate",
+			rotated:    true,
+		},
+		{
+			name:       "size equal to offset",
+			prev:       fileSnapshot{dev: 1, inode: 1, size: 100},
+			snapshot:   fileSnapshot{dev: 1, inode: 1, size: 100},
+			lastOffset: 100,
+			rotated:    false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reason, rotated := rotationReason(c.prev, c.snapshot, c.lastOffset)
+			if reason != c.reason || rotated != c.rotated {
+				t.Fatalf("rotationReason() = (%q, %v), want (%q, %v)", reason, rotated, c.reason, c.rotated)
+			}
+		})
+	}
+}
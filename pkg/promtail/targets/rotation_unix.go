@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package targets
+
+import (
+	"os"
+	"syscall"
+)
+
+func statSnapshot(path string) (fileSnapshot, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileSnapshot{}, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileSnapshot{size: fi.Size()}, nil
+	}
+	return fileSnapshot{dev: uint64(st.Dev), inode: st.Ino, size: fi.Size()}, nil
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package targets
+
+import "os"
+
+// Windows has no stable inode we can read through os.FileInfo, so rotation
+// detection there falls back to size shrink (truncate) only.
+func statSnapshot(path string) (fileSnapshot, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileSnapshot{}, err
+	}
+	return fileSnapshot{size: fi.Size()}, nil
+}
@@ -1,7 +1,11 @@
 package targets
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -13,6 +17,10 @@ import (
 	"github.com/grafana/loki/pkg/promtail/positions"
 )
 
+// framedPollPeriod is how often a non-line-framed tailer retries reading
+// past EOF while following a growing file.
+const framedPollPeriod = 250 * time.Millisecond
+
 type tailer struct {
 	logger    log.Logger
 	handler   api.EntryHandler
@@ -22,33 +30,64 @@ type tailer struct {
 	filename string
 	tail     *tail.Tail
 
+	rateLimit        *leakyBucket
+	rateLimitDropped int64
+
+	multiline *MultilineConfig
+	mlBuf     multilineBuffer
+	mlTimer   *time.Timer
+
+	snapshot   fileSnapshot
+	lastOffset int64
+
+	framing    *FramingConfig
+	file       *os.File
+	reader     *bufio.Reader
+	byteOffset int64
+
+	lineLimits     LineLimitConfig
+	inSplitLine    bool
+	truncatingLine bool
+	splitLine      strings.Builder
+	splitLineBytes int
+
 	quit chan struct{}
 	done chan struct{}
 }
 
-func newTailer(logger log.Logger, handler api.EntryHandler, positions *positions.Positions, path string) (*tailer, error) {
-	filename := path
-	var reOpen bool
-
-	// Check if the path requested is a symbolic link
+// resolveFilename follows path if it is a symlink, returning the underlying
+// filename to tail and whether the tail library should auto re-open it
+// (symlinks don't generate a Create event on rotation, so we must).
+func resolveFilename(path string) (filename string, reOpen bool, err error) {
 	fi, err := os.Lstat(path)
 	if err != nil {
-		return nil, err
+		return "", false, err
 	}
+	filename = path
 	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
 		filename, err = os.Readlink(path)
 		if err != nil {
-			return nil, err
+			return "", false, err
 		}
-
-		// if we are tailing a symbolic link then we need to automatically re-open
-		// as we wont get a Create event when a file is rotated.
 		reOpen = true
 	}
+	return filename, reOpen, nil
+}
+
+func newTailer(logger log.Logger, handler api.EntryHandler, positions *positions.Positions, path string, rateLimit *RateLimitConfig, multiline *MultilineConfig, framing *FramingConfig, lineLimits LineLimitConfig) (*tailer, error) {
+	if framing != nil && framing.Mode != FramingLine {
+		return newFramedTailer(logger, handler, positions, path, framing)
+	}
+
+	filename, reOpen, err := resolveFilename(path)
+	if err != nil {
+		return nil, err
+	}
 
 	tail, err := tail.TailFile(filename, tail.Config{
-		Follow: true,
-		ReOpen: reOpen,
+		Follow:      true,
+		ReOpen:      reOpen,
+		MaxLineSize: lineLimits.MaxLineSize,
 		Location: &tail.SeekInfo{
 			Offset: positions.Get(filename),
 			Whence: 0,
@@ -58,6 +97,57 @@ func newTailer(logger log.Logger, handler api.EntryHandler, positions *positions
 		return nil, err
 	}
 
+	tailer := &tailer{
+		logger:    logger,
+		handler:   api.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
+		positions: positions,
+
+		path:       path,
+		filename:   filename,
+		tail:       tail,
+		lineLimits: lineLimits,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if rateLimit != nil {
+		tailer.rateLimit = newLeakyBucket(*rateLimit)
+	}
+	if multiline != nil && multiline.FirstLine != nil {
+		tailer.multiline = multiline
+		tailer.mlTimer = time.NewTimer(multiline.MaxWait)
+		if !tailer.mlTimer.Stop() {
+			<-tailer.mlTimer.C
+		}
+	}
+	if snapshot, err := statSnapshot(path); err == nil {
+		tailer.snapshot = snapshot
+	}
+	go tailer.run()
+	filesActive.Add(1.)
+	return tailer, nil
+}
+
+// newFramedTailer builds a tailer that reads the file directly with a
+// bufio.Reader instead of going through hpcloud/tail, for framing modes that
+// aren't plain newline-delimited lines.
+func newFramedTailer(logger log.Logger, handler api.EntryHandler, positions *positions.Positions, path string, framing *FramingConfig) (*tailer, error) {
+	filename, _, err := resolveFilename(path)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := positions.Get(filename)
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
 	tailer := &tailer{
 		logger:    logger,
 		handler:   api.AddLabelsMiddleware(model.LabelSet{filenameLabel: model.LabelValue(path)}).Wrap(handler),
@@ -65,15 +155,155 @@ func newTailer(logger log.Logger, handler api.EntryHandler, positions *positions
 
 		path:     path,
 		filename: filename,
-		tail:     tail,
 		quit:     make(chan struct{}),
 		done:     make(chan struct{}),
+
+		framing:    framing,
+		file:       f,
+		reader:     bufio.NewReader(f),
+		byteOffset: offset,
 	}
-	go tailer.run()
+	if snapshot, err := statSnapshot(path); err == nil {
+		tailer.snapshot = snapshot
+	}
+	go tailer.runFramed()
 	filesActive.Add(1.)
 	return tailer, nil
 }
 
+// runFramed polls the file for new framed records, since regular files
+// can't be followed through a blocking read the way hpcloud/tail follows
+// line-oriented ones.
+func (t *tailer) runFramed() {
+	level.Info(t.logger).Log("msg", "start tailing file", "path", t.path, "framing", t.framing.Mode)
+	positionSyncPeriod := t.positions.SyncPeriod()
+	positionWait := time.NewTicker(positionSyncPeriod)
+
+	defer func() {
+		positionWait.Stop()
+		close(t.done)
+	}()
+
+	for {
+		select {
+		case <-positionWait.C:
+			t.positions.Put(t.filename, t.byteOffset)
+
+			if snapshot, err := statSnapshot(t.path); err != nil {
+				level.Error(t.logger).Log("msg", "failed to stat path for rotation detection", "path", t.path, "error", err)
+			} else {
+				if reason, rotated := rotationReason(t.snapshot, snapshot, t.byteOffset); rotated {
+					rotationsTotal.WithLabelValues(t.path, reason).Inc()
+					if err := t.rotateFramed(reason); err != nil {
+						level.Error(t.logger).Log("msg", "error reopening rotated framed file", "path", t.path, "error", err)
+					}
+					snapshot, _ = statSnapshot(t.path)
+				}
+				t.snapshot = snapshot
+			}
+		case <-t.quit:
+			return
+		default:
+		}
+
+		frame, n, err := t.readFrame()
+		if err != nil {
+			if err != io.EOF || n > 0 {
+				framingErrors.WithLabelValues(t.path).Inc()
+			}
+			if err != io.EOF {
+				level.Error(t.logger).Log("msg", "error framing record, resyncing", "path", t.path, "error", err)
+			}
+			if n > 0 {
+				// A partial frame was buffered and consumed from the reader
+				// without advancing byteOffset; reopen at the last confirmed
+				// offset so the next attempt re-reads it cleanly. On a clean,
+				// zero-byte EOF (the steady-state idle-file case) there's
+				// nothing to resync: a plain re-read past EOF already picks
+				// up newly appended data.
+				if rerr := t.resyncReader(); rerr != nil {
+					level.Error(t.logger).Log("msg", "error resyncing framed reader", "path", t.path, "error", rerr)
+				}
+			}
+			select {
+			case <-t.quit:
+				return
+			case <-time.After(framedPollPeriod):
+			}
+			continue
+		}
+
+		t.byteOffset += int64(n)
+		readLines.WithLabelValues(t.path).Inc()
+		readBytes.WithLabelValues(t.path).Add(float64(n))
+		t.emit(time.Now(), string(frame))
+	}
+}
+
+// readFrame reads one framed record according to t.framing.Mode.
+func (t *tailer) readFrame() ([]byte, int, error) {
+	switch t.framing.Mode {
+	case FramingDelimiter:
+		return readDelimitedFrame(t.reader, t.framing.Delimiter)
+	case FramingLengthPrefixed:
+		return readLengthPrefixedFrame(t.reader, t.framing.MaxFrameBytes)
+	default:
+		return nil, 0, io.EOF
+	}
+}
+
+// resyncReader drops any bytes the bufio.Reader speculatively buffered past
+// a partial frame and reopens the file at the last confirmed offset, so the
+// next readFrame call starts clean once more data has been written.
+func (t *tailer) resyncReader() error {
+	if err := t.file.Close(); err != nil {
+		level.Error(t.logger).Log("msg", "error closing framed file", "path", t.path, "error", err)
+	}
+	f, err := os.Open(t.filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(t.byteOffset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	t.file = f
+	t.reader = bufio.NewReader(f)
+	return nil
+}
+
+// rotateFramed swaps in a freshly opened file handle on t.path after a
+// rename or truncate is detected, mirroring rotate() for the line-oriented
+// tailer. Unlike resyncReader, which reopens at the last confirmed offset to
+// resync a partial frame, this always starts over from byte 0, since the
+// old offset may no longer exist in the new file.
+func (t *tailer) rotateFramed(reason string) error {
+	level.Info(t.logger).Log("msg", "file rotated, reopening", "path", t.path, "reason", reason)
+
+	oldFilename := t.filename
+	if err := t.file.Close(); err != nil {
+		level.Error(t.logger).Log("msg", "error closing rotated framed file", "path", t.path, "error", err)
+	}
+
+	filename, _, err := resolveFilename(t.path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	t.file = f
+	t.reader = bufio.NewReader(f)
+	t.filename = filename
+	t.byteOffset = 0
+	t.positions.Remove(oldFilename)
+	t.positions.Put(filename, 0)
+	return nil
+}
+
 func (t *tailer) run() {
 	level.Info(t.logger).Log("msg", "start tailing file", "path", t.path)
 	positionSyncPeriod := t.positions.SyncPeriod()
@@ -85,7 +315,15 @@ func (t *tailer) run() {
 	}()
 
 	for {
+		var mlTimerC <-chan time.Time
+		if t.mlTimer != nil {
+			mlTimerC = t.mlTimer.C
+		}
+
 		select {
+		case <-mlTimerC:
+			t.flushMultiline()
+
 		case <-positionWait.C:
 			fi, err := os.Stat(t.filename)
 			if err != nil {
@@ -100,6 +338,17 @@ func (t *tailer) run() {
 				continue
 			}
 
+			if snapshot, err := statSnapshot(t.path); err != nil {
+				level.Error(t.logger).Log("msg", "failed to stat path for rotation detection", "path", t.path, "error", err)
+			} else {
+				if reason, rotated := rotationReason(t.snapshot, snapshot, t.lastOffset); rotated {
+					rotationsTotal.WithLabelValues(t.path, reason).Inc()
+					t.rotate(reason)
+					snapshot, _ = statSnapshot(t.path)
+				}
+				t.snapshot = snapshot
+			}
+
 		case line, ok := <-t.tail.Lines:
 			if !ok {
 				return
@@ -113,16 +362,91 @@ func (t *tailer) run() {
 			// The line we receive from the tailer is stripped of the newline character, which causes counts to be
 			// off between the file size and this metric of bytes read, so we are adding back a byte to represent the newline
 			// If you are reading this you are probably using Windows which has a 2 byte /r/n newline string.... sorry
-			readBytes.WithLabelValues(t.path).Add(float64(len(line.Text) + 1))
-			if err := t.handler.Handle(model.LabelSet{}, line.Time, line.Text); err != nil {
-				level.Error(t.logger).Log("msg", "error handling line", "path", t.path, "error", err)
+			// assembleSplitLine now does that accounting, and leaves the byte off fragments that MaxLineSize split mid-line.
+			text, ready := t.assembleSplitLine(line.Text)
+			if !ready {
+				continue
 			}
+			line.Text = text
+
+			if t.rateLimit != nil && !t.rateLimit.admit() {
+				droppedLines.WithLabelValues(t.path, "rate_limited").Inc()
+				t.rateLimitDropped++
+				// A dropped line could have been the start of, or a
+				// continuation in, a multiline entry; flush what's buffered
+				// so far rather than risk silently splicing unrelated
+				// content across the gap.
+				if t.multiline != nil {
+					t.flushMultiline()
+				}
+				continue
+			}
+
+			if t.rateLimitDropped > 0 {
+				msg := fmt.Sprintf("rate limit exceeded, %d lines dropped", t.rateLimitDropped)
+				if err := t.handler.Handle(model.LabelSet{}, line.Time, msg); err != nil {
+					level.Error(t.logger).Log("msg", "error handling rate limit notice", "path", t.path, "error", err)
+				}
+				t.rateLimitDropped = 0
+			}
+
+			t.handleEntry(line.Time, line.Text)
 		case <-t.quit:
 			return
 		}
 	}
 }
 
+// handleEntry routes a physical line either straight to the handler, or
+// through the multiline buffer when multiline assembly is configured.
+func (t *tailer) handleEntry(ts time.Time, text string) {
+	if t.multiline == nil {
+		t.emit(ts, text)
+		return
+	}
+
+	if t.mlBuf.empty() || t.multiline.FirstLine.MatchString(text) {
+		t.flushMultiline()
+	}
+
+	t.mlBuf.append(ts, text)
+	overLines := t.multiline.MaxLines > 0 && t.mlBuf.lines >= t.multiline.MaxLines
+	overBytes := t.multiline.MaxBytes > 0 && t.mlBuf.text.Len() >= t.multiline.MaxBytes
+	if overLines || overBytes {
+		entriesTruncated.WithLabelValues(t.path).Inc()
+		t.flushMultiline()
+		return
+	}
+	t.resetMultilineTimer()
+}
+
+func (t *tailer) resetMultilineTimer() {
+	if !t.mlTimer.Stop() {
+		select {
+		case <-t.mlTimer.C:
+		default:
+		}
+	}
+	t.mlTimer.Reset(t.multiline.MaxWait)
+}
+
+// flushMultiline emits the buffered multiline entry, if any, using the
+// timestamp of the line that started it.
+func (t *tailer) flushMultiline() {
+	if t.mlBuf.empty() {
+		return
+	}
+	t.emit(t.mlBuf.firstTime, t.mlBuf.text.String())
+	t.mlBuf.reset()
+}
+
+func (t *tailer) emit(ts time.Time, text string) {
+	entriesEmitted.WithLabelValues(t.path).Inc()
+	if err := t.handler.Handle(model.LabelSet{}, ts, text); err != nil {
+		level.Error(t.logger).Log("msg", "error handling line", "path", t.path, "error", err)
+	}
+}
+
 func (t *tailer) markPosition() error {
 	pos, err := t.tail.Tell()
 	if err != nil {
@@ -130,10 +454,60 @@ func (t *tailer) markPosition() error {
 	}
 	level.Debug(t.logger).Log("path", t.path, "filename", t.filename, "current_position", pos)
 	t.positions.Put(t.filename, pos)
+	t.lastOffset = pos
 	return nil
 }
 
+// rotate swaps in a fresh *tail.Tail on t.path after a rename or truncate is
+// detected, preserving the handler/labels already wrapped around t.handler.
+// The old file's saved position is only dropped once the new tail is up, so
+// a crash mid-rotation still leaves a recoverable position behind.
+func (t *tailer) rotate(reason string) {
+	level.Info(t.logger).Log("msg", "file rotated, reopening", "path", t.path, "reason", reason)
+
+	oldFilename := t.filename
+	if err := t.tail.Stop(); err != nil {
+		level.Error(t.logger).Log("msg", "error stopping tail on rotated file", "path", t.path, "error", err)
+	}
+
+	filename, reOpen, err := resolveFilename(t.path)
+	if err != nil {
+		level.Error(t.logger).Log("msg", "error resolving path after rotation", "path", t.path, "error", err)
+		return
+	}
+
+	newTail, err := tail.TailFile(filename, tail.Config{
+		Follow:      true,
+		ReOpen:      reOpen,
+		MaxLineSize: t.lineLimits.MaxLineSize,
+		Location: &tail.SeekInfo{
+			Offset: 0,
+			Whence: 0,
+		},
+	})
+	if err != nil {
+		level.Error(t.logger).Log("msg", "error reopening rotated file", "path", t.path, "error", err)
+		return
+	}
+
+	t.tail = newTail
+	t.filename = filename
+	t.lastOffset = 0
+	t.positions.Remove(oldFilename)
+	t.positions.Put(filename, 0)
+}
+
 func (t *tailer) stop() error {
+	if t.framing != nil {
+		t.positions.Put(t.filename, t.byteOffset)
+		close(t.quit)
+		<-t.done
+		err := t.file.Close()
+		filesActive.Add(-1.)
+		level.Info(t.logger).Log("msg", "stopped tailing file", "path", t.path)
+		return err
+	}
+
 	// Save the current position before shutting down tailer
 	err := t.markPosition()
 	if err != nil {
@@ -142,6 +516,9 @@ func (t *tailer) stop() error {
 	err = t.tail.Stop()
 	close(t.quit)
 	<-t.done
+	if t.multiline != nil {
+		t.flushMultiline()
+	}
 	filesActive.Add(-1.)
 	level.Info(t.logger).Log("msg", "stopped tailing file", "path", t.path)
 	return err